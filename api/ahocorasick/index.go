@@ -0,0 +1,412 @@
+package ahocorasick
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// indexMagic identifies a file written by WriteIndex, distinguishing it
+// from an unrelated file passed to LoadIndex by mistake.
+const indexMagic uint32 = 0x41484331 // "AHC1"
+
+// indexVersion is bumped whenever the on-disk layout changes incompatibly.
+const indexVersion uint16 = 1
+
+// denseChildThreshold is the number of children at or above which a state's
+// children are written as a dense rune-indexed array instead of a sorted
+// list of (rune, child id) pairs, provided the span of rune values they
+// cover is at most four times their number.
+const denseChildThreshold = 32
+
+const (
+	childModeSparse uint8 = 0
+	childModeDense  uint8 = 1
+)
+
+// WriteIndex persists the trie rooted at s to w in a compact binary format:
+// a header (magic, version, term count), a term-string table, and a states
+// table giving each state's id, isWord flag, lps id, output set, and
+// children - encoded as sorted (rune, child id) pairs for sparse states, or
+// a dense rune-indexed array once a state's fan-out reaches
+// denseChildThreshold. The layout uses only fixed-size integers and
+// length-prefixed strings, but each state's record has a variable length
+// and there is no table of record offsets, so a state cannot be located
+// without reading every record before it: the index is read back
+// sequentially.
+func (s *SearchTrie) WriteIndex(w io.Writer) error {
+	root := s.root
+	nodes := collectNodes(root)
+
+	terms, termIndex := collectTerms(nodes)
+
+	bw := bufio.NewWriter(w)
+
+	if err := binary.Write(bw, binary.BigEndian, indexMagic); nil != err {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, indexVersion); nil != err {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, uint32(len(terms))); nil != err {
+		return err
+	}
+	for _, term := range terms {
+		if err := writeString(bw, term); nil != err {
+			return err
+		}
+	}
+
+	if err := binary.Write(bw, binary.BigEndian, uint32(len(nodes))); nil != err {
+		return err
+	}
+	for id := 0; id < len(nodes); id++ {
+		if err := writeState(bw, nodes[id], termIndex); nil != err {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeState(w *bufio.Writer, n *SearchTrie, termIndex map[string]uint32) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(n.id)); nil != err {
+		return err
+	}
+
+	var isWord uint8
+	if n.isWord {
+		isWord = 1
+	}
+	if err := binary.Write(w, binary.BigEndian, isWord); nil != err {
+		return err
+	}
+
+	lpsID := int32(-1)
+	if nil != n.lps {
+		lpsID = int32(n.lps.id)
+	}
+	if err := binary.Write(w, binary.BigEndian, lpsID); nil != err {
+		return err
+	}
+
+	outputs := make([]uint32, 0, len(n.ot))
+	for term := range n.ot {
+		outputs = append(outputs, termIndex[term])
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i] < outputs[j] })
+
+	if err := binary.Write(w, binary.BigEndian, uint32(len(outputs))); nil != err {
+		return err
+	}
+	for _, idx := range outputs {
+		if err := binary.Write(w, binary.BigEndian, idx); nil != err {
+			return err
+		}
+	}
+
+	return writeChildren(w, n)
+}
+
+func writeChildren(w *bufio.Writer, n *SearchTrie) error {
+	if 0 == len(n.children) {
+		if err := binary.Write(w, binary.BigEndian, childModeSparse); nil != err {
+			return err
+		}
+		return binary.Write(w, binary.BigEndian, uint32(0))
+	}
+
+	runes := make([]rune, 0, len(n.children))
+	for r := range n.children {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	min, max := runes[0], runes[len(runes)-1]
+	span := int(max-min) + 1
+
+	if len(runes) >= denseChildThreshold && span <= len(runes)*4 {
+		if err := binary.Write(w, binary.BigEndian, childModeDense); nil != err {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint32(min)); nil != err {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint32(max)); nil != err {
+			return err
+		}
+		for r := min; r <= max; r++ {
+			childID := int32(-1)
+			if child, ok := n.children[r]; ok {
+				childID = int32(child.id)
+			}
+			if err := binary.Write(w, binary.BigEndian, childID); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := binary.Write(w, binary.BigEndian, childModeSparse); nil != err {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(runes))); nil != err {
+		return err
+	}
+	for _, r := range runes {
+		if err := binary.Write(w, binary.BigEndian, uint32(r)); nil != err {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint32(n.children[r].id)); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeString(w *bufio.Writer, s string) error {
+	if err := binary.Write(w, binary.BigEndian, uint16(len(s))); nil != err {
+		return err
+	}
+	_, err := w.WriteString(s)
+	return err
+}
+
+// collectNodes returns every node in root's trie, indexed by id; id 0 is
+// the root itself.
+func collectNodes(root *SearchTrie) []*SearchTrie {
+	nodes := make([]*SearchTrie, root.nextID)
+	nodes[root.id] = root
+
+	var visit func(n *SearchTrie)
+	visit = func(n *SearchTrie) {
+		for _, child := range n.children {
+			nodes[child.id] = child
+			visit(child)
+		}
+	}
+	visit(root)
+
+	return nodes
+}
+
+// collectTerms gathers the distinct terms present in any node's output
+// set, in a deterministic (sorted) order, along with the index each was
+// assigned.
+func collectTerms(nodes []*SearchTrie) ([]string, map[string]uint32) {
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		for term := range n.ot {
+			seen[term] = true
+		}
+	}
+
+	terms := make([]string, 0, len(seen))
+	for term := range seen {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	termIndex := make(map[string]uint32, len(terms))
+	for i, term := range terms {
+		termIndex[term] = uint32(i)
+	}
+
+	return terms, termIndex
+}
+
+// LoadIndex reads an index previously written by (*SearchTrie).WriteIndex
+// and returns the equivalent SearchTrie, ready to Search.
+func LoadIndex(r io.ReaderAt) (*SearchTrie, error) {
+	sr := &sectionReader{r: r}
+
+	var magic uint32
+	if err := binary.Read(sr, binary.BigEndian, &magic); nil != err {
+		return nil, err
+	}
+	if indexMagic != magic {
+		return nil, fmt.Errorf("ahocorasick: not an index file (bad magic %#x)", magic)
+	}
+
+	var version uint16
+	if err := binary.Read(sr, binary.BigEndian, &version); nil != err {
+		return nil, err
+	}
+	if indexVersion != version {
+		return nil, fmt.Errorf("ahocorasick: unsupported index version %d", version)
+	}
+
+	var termCount uint32
+	if err := binary.Read(sr, binary.BigEndian, &termCount); nil != err {
+		return nil, err
+	}
+	terms := make([]string, termCount)
+	for i := range terms {
+		term, err := readString(sr)
+		if nil != err {
+			return nil, err
+		}
+		terms[i] = term
+	}
+
+	var stateCount uint32
+	if err := binary.Read(sr, binary.BigEndian, &stateCount); nil != err {
+		return nil, err
+	}
+
+	nodes := make([]*SearchTrie, stateCount)
+	lpsIDs := make([]int32, stateCount)
+
+	type pendingChild struct {
+		r       rune
+		childID int32
+	}
+	children := make([][]pendingChild, stateCount)
+
+	for i := uint32(0); i < stateCount; i++ {
+		var id uint32
+		if err := binary.Read(sr, binary.BigEndian, &id); nil != err {
+			return nil, err
+		}
+
+		var isWord uint8
+		if err := binary.Read(sr, binary.BigEndian, &isWord); nil != err {
+			return nil, err
+		}
+
+		var lpsID int32
+		if err := binary.Read(sr, binary.BigEndian, &lpsID); nil != err {
+			return nil, err
+		}
+
+		var outputCount uint32
+		if err := binary.Read(sr, binary.BigEndian, &outputCount); nil != err {
+			return nil, err
+		}
+		ot := make(map[string]bool, outputCount)
+		for j := uint32(0); j < outputCount; j++ {
+			var termIdx uint32
+			if err := binary.Read(sr, binary.BigEndian, &termIdx); nil != err {
+				return nil, err
+			}
+			if termIdx >= uint32(len(terms)) {
+				return nil, fmt.Errorf("ahocorasick: term index %d out of range", termIdx)
+			}
+			ot[terms[termIdx]] = true
+		}
+
+		var childMode uint8
+		if err := binary.Read(sr, binary.BigEndian, &childMode); nil != err {
+			return nil, err
+		}
+
+		var kids []pendingChild
+		switch childMode {
+		case childModeSparse:
+			var childCount uint32
+			if err := binary.Read(sr, binary.BigEndian, &childCount); nil != err {
+				return nil, err
+			}
+			kids = make([]pendingChild, childCount)
+			for k := range kids {
+				var r, childID uint32
+				if err := binary.Read(sr, binary.BigEndian, &r); nil != err {
+					return nil, err
+				}
+				if err := binary.Read(sr, binary.BigEndian, &childID); nil != err {
+					return nil, err
+				}
+				kids[k] = pendingChild{r: rune(r), childID: int32(childID)}
+			}
+		case childModeDense:
+			var min, max uint32
+			if err := binary.Read(sr, binary.BigEndian, &min); nil != err {
+				return nil, err
+			}
+			if err := binary.Read(sr, binary.BigEndian, &max); nil != err {
+				return nil, err
+			}
+			for r := min; r <= max; r++ {
+				var childID int32
+				if err := binary.Read(sr, binary.BigEndian, &childID); nil != err {
+					return nil, err
+				}
+				if -1 != childID {
+					kids = append(kids, pendingChild{r: rune(r), childID: childID})
+				}
+			}
+		default:
+			return nil, fmt.Errorf("ahocorasick: unknown child encoding %d", childMode)
+		}
+
+		node := &SearchTrie{
+			id:       int(id),
+			isWord:   1 == isWord,
+			children: make(map[rune]*SearchTrie),
+			ilps:     make(map[int]*SearchTrie),
+			ot:       ot,
+		}
+		nodes[id] = node
+		lpsIDs[id] = lpsID
+		children[id] = kids
+	}
+
+	root := nodes[0]
+	root.root = root
+	root.nextID = int(stateCount)
+
+	for id, node := range nodes {
+		if 0 == id {
+			continue
+		}
+		node.root = root
+	}
+
+	for id, lpsID := range lpsIDs {
+		if -1 == lpsID {
+			continue
+		}
+		node := nodes[id]
+		node.lps = nodes[lpsID]
+		node.lps.ilps[node.id] = node
+	}
+
+	for id, kids := range children {
+		node := nodes[id]
+		for _, k := range kids {
+			child := nodes[k.childID]
+			child.char = k.r
+			node.children[k.r] = child
+		}
+	}
+
+	return root, nil
+}
+
+func readString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); nil != err {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); nil != err {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// sectionReader adapts an io.ReaderAt into a sequential io.Reader, so the
+// on-disk layout - written sequentially - can also be read back
+// sequentially from a source chosen for random-access (mmap) friendliness.
+type sectionReader struct {
+	r   io.ReaderAt
+	off int64
+}
+
+func (s *sectionReader) Read(p []byte) (int, error) {
+	n, err := s.r.ReadAt(p, s.off)
+	s.off += int64(n)
+	return n, err
+}
@@ -0,0 +1,94 @@
+package ahocorasick
+
+import (
+	"io"
+	"regexp"
+	"sync"
+
+	"github.com/trevor-leach/multisearch/api"
+)
+
+// regexWindow bounds how many runes are buffered between newlines before a
+// chunk is handed off for pattern matching, so a single very long line -
+// or text with no newlines at all - doesn't grow the buffer without limit.
+const regexWindow = 8192
+
+// regexChunk is a line-sized piece of text queued for pattern matching,
+// together with the byte offset at which it began in the original stream.
+type regexChunk struct {
+	text       string
+	startIndex int
+}
+
+// searchPatterns tees r between the Aho-Corasick pass, run on the calling
+// goroutine exactly as searchLiteral does, and the trie's compiled
+// patterns, matched by a second goroutine against line-sized chunks split
+// at '\n' (or every regexWindow runes, for a line with none). Matches from
+// either side are sent to ch.
+func (s SearchTrie) searchPatterns(r io.RuneReader, ch chan<- api.Match) {
+	chunks := make(chan regexChunk, 2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for chunk := range chunks {
+			matchPatterns(s.root.patterns, chunk, ch)
+		}
+	}()
+
+	n := &s
+	index := 0
+	chunkStart := 0
+	var buf []rune
+
+	flush := func() {
+		if 0 == len(buf) {
+			return
+		}
+		chunks <- regexChunk{text: string(buf), startIndex: chunkStart}
+		buf = nil
+	}
+
+	for {
+		char, nbytes, err := r.ReadRune()
+		if nil != err {
+			break
+		}
+		index += nbytes
+
+		n = acStep(n, char)
+		acEmit(n, index, ch)
+
+		if '\n' == char {
+			buf = append(buf, char)
+			flush()
+			chunkStart = index
+			continue
+		}
+
+		buf = append(buf, char)
+		if len(buf) >= regexWindow {
+			flush()
+			chunkStart = index
+		}
+	}
+	flush()
+
+	close(chunks)
+	wg.Wait()
+}
+
+// matchPatterns runs every pattern against chunk.text, translating the
+// byte offsets regexp reports - relative to chunk.text - back into
+// absolute offsets in the original stream.
+func matchPatterns(patterns []*regexp.Regexp, chunk regexChunk, ch chan<- api.Match) {
+	for _, re := range patterns {
+		for _, loc := range re.FindAllStringIndex(chunk.text, -1) {
+			ch <- api.Match{
+				Term:     re.String(),
+				Location: [2]int{chunk.startIndex + loc[0], chunk.startIndex + loc[1]},
+			}
+		}
+	}
+}
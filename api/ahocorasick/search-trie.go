@@ -4,12 +4,11 @@ import (
 	"container/list"
 	"encoding/json"
 	"io"
+	"regexp"
 
 	"github.com/trevor-leach/multisearch/api"
 )
 
-var id int = -1
-
 // SearchTrie see https://en.wikipedia.org/wiki/Aho%E2%80%93Corasick_algorithm
 // and  http://se.ethz.ch/~meyer/publications/string/string_matching.pdf
 type SearchTrie struct {
@@ -21,14 +20,16 @@ type SearchTrie struct {
 	lps      *SearchTrie         // longest proper suffix.
 	ilps     map[int]*SearchTrie // inverse lps
 	ot       map[string]bool     // set of suffixes that are full words in this trie.
+	nextID   int                 // next unused id; only meaningful on the root node.
+	patterns []*regexp.Regexp    // regex terms added via AddPatternTerm; only meaningful on the root node.
 }
 
 // New returns an initialized SearchTrie.
 func New(searchStrings []string) *SearchTrie {
-	id++
 	s := new(SearchTrie)
-	s.id = id
 	s.root = s
+	s.id = 0
+	s.nextID = 1
 	s.children = make(map[rune]*SearchTrie)
 	s.ilps = make(map[int]*SearchTrie)
 	s.ot = make(map[string]bool)
@@ -38,6 +39,15 @@ func New(searchStrings []string) *SearchTrie {
 	return s
 }
 
+// allocID hands out the next unused id in s's trie, so that no two nodes of
+// one trie share an id. Each trie numbers its own nodes from 0,
+// independently of any other trie.
+func (s *SearchTrie) allocID() int {
+	id := s.root.nextID
+	s.root.nextID++
+	return id
+}
+
 // isRoot gets whether s is the root of the trie or not.
 func (s *SearchTrie) isRoot() bool {
 	return s == s.root
@@ -61,35 +71,75 @@ func (s *SearchTrie) getChild(char rune) *SearchTrie {
 	return s.root
 }
 
-// Search searches the specified text for the previously added search strings.
+// Search searches the specified text for the previously added search
+// strings and patterns. If any patterns were added with AddPatternTerm,
+// the text is also scanned, line by line and concurrently with the
+// Aho-Corasick pass, against those compiled regular expressions; see
+// searchPatterns.
 func (s SearchTrie) Search(r io.RuneReader) <-chan api.Match {
 	ch := make(chan api.Match, 2)
+	if 0 == len(s.root.patterns) {
+		go func() {
+			defer close(ch)
+			s.searchLiteral(r, ch)
+		}()
+		return ch
+	}
+
 	go func() {
-		n := &s
-		index := 0
-		for {
-			char, nbytes, err := r.ReadRune() // returns rune, nbytes, error
-			if nil != err {
-				break
-			}
-			index += nbytes
-
-			for !n.isRoot() && n.children[char] == nil {
-				n = n.failureFn()
-			}
-			n = n.getChild(char)
-
-			for t := range n.ot {
-				ch <- api.Match{
-					Term:     t,
-					Location: [2]int{index - len(t), index}}
-			}
-		}
-		close(ch)
+		defer close(ch)
+		s.searchPatterns(r, ch)
 	}()
 	return ch
 }
 
+// searchLiteral runs only the Aho-Corasick pass over r, emitting a Match
+// for every literal search term found.
+func (s SearchTrie) searchLiteral(r io.RuneReader, ch chan<- api.Match) {
+	n := &s
+	index := 0
+	for {
+		char, nbytes, err := r.ReadRune() // returns rune, nbytes, error
+		if nil != err {
+			break
+		}
+		index += nbytes
+
+		n = acStep(n, char)
+		acEmit(n, index, ch)
+	}
+}
+
+// acStep advances the Aho-Corasick state machine by one character.
+func acStep(n *SearchTrie, char rune) *SearchTrie {
+	for !n.isRoot() && n.children[char] == nil {
+		n = n.failureFn()
+	}
+	return n.getChild(char)
+}
+
+// acEmit sends a Match for every literal term ending at the current state.
+func acEmit(n *SearchTrie, index int, ch chan<- api.Match) {
+	for t := range n.ot {
+		ch <- api.Match{
+			Term:     t,
+			Location: [2]int{index - len(t), index}}
+	}
+}
+
+// AddPatternTerm compiles pattern as a regular expression and adds it to
+// the set of patterns searched for alongside the literal search terms.
+// Unlike AddSearchTerm, a pattern match's Match.Term is the original
+// pattern text rather than the matched substring.
+func (s SearchTrie) AddPatternTerm(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if nil != err {
+		return err
+	}
+	s.root.patterns = append(s.root.patterns, re)
+	return nil
+}
+
 // AddSearchTerm adds another search string to the Searcher.
 func (s SearchTrie) AddSearchTerm(searchTerm string) {
 	s.enterInTrie(searchTerm)
@@ -117,9 +167,8 @@ func (s *SearchTrie) enterInTrie(str string) {
 }
 
 func (s *SearchTrie) enterChild(char rune) *SearchTrie {
-	id++
 	child := &SearchTrie{
-		id:       id,
+		id:       s.root.allocID(),
 		root:     s.root,
 		char:     char,
 		children: make(map[rune]*SearchTrie),
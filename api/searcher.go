@@ -14,7 +14,18 @@ type Match struct {
 }
 
 // Searcher is a widget that can search some Text for a set of search terms.
+//
+// Search is safe to call concurrently from multiple goroutines against the
+// same Searcher, each with its own io.RuneReader: implementations must not
+// mutate any state shared between searches once construction (AddSearchTerm
+// calls included) has finished. This lets a single Searcher be handed to a
+// pool of workers searching different files in parallel.
 type Searcher interface {
 	AddSearchTerm(searchTerm string)
+	// AddPatternTerm adds a regular expression to search for alongside any
+	// literal search terms. A pattern match's Match.Term is the original
+	// pattern text rather than the matched substring. It returns an error
+	// if pattern fails to compile.
+	AddPatternTerm(pattern string) error
 	Search(r io.RuneReader) <-chan Match
 }
@@ -0,0 +1,84 @@
+package walker
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Cache remembers a directory's listing by inode, so a directory seen by
+// one Walk call is not re-read from disk by a later Walk call sharing the
+// same Cache. This matters once a single process walks the same
+// searchpath more than once, such as a watch mode re-scanning after a
+// change notification. Entries are never invalidated: a directory whose
+// contents change after it was cached is still served its old listing.
+// The zero value is not usable; use NewCache.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[uint64][]os.FileInfo
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[uint64][]os.FileInfo)}
+}
+
+// ReadDir returns the directory entries of path, consulting and populating
+// the cache exactly as a Walk over path would. Exported so a caller that
+// re-examines part of a tree outside of Walk - such as watch mode adding an
+// fsnotify watch to a newly created subdirectory - can reuse entries a
+// previous Walk with the same Cache already read.
+func (c *Cache) ReadDir(path string) ([]os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if nil != err {
+		return nil, err
+	}
+	return c.readDir(path, info)
+}
+
+// readDir returns the directory entries of path, consulting and
+// populating the cache when the directory's inode can be determined. A nil
+// Cache disables caching but still reads the directory.
+func (c *Cache) readDir(path string, info os.FileInfo) ([]os.FileInfo, error) {
+	ino, ok := uint64(0), false
+	if nil != c {
+		ino, ok = inode(info)
+		if ok {
+			c.mu.Lock()
+			cached, found := c.entries[ino]
+			c.mu.Unlock()
+			if found {
+				return cached, nil
+			}
+		}
+	}
+
+	f, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := f.Readdir(-1)
+	if nil != err {
+		return nil, err
+	}
+
+	if nil != c && ok {
+		c.mu.Lock()
+		c.entries[ino] = entries
+		c.mu.Unlock()
+	}
+
+	return entries, nil
+}
+
+// inode returns the inode number backing info, when the platform's
+// os.FileInfo.Sys() exposes one.
+func inode(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return stat.Ino, true
+}
@@ -0,0 +1,173 @@
+// Package walker walks a directory tree, applying include/exclude glob
+// filters and a prune list of directory names to skip, the way the "find"
+// command's emulators do.
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures a Walk over a directory tree.
+type Options struct {
+	// Recursive controls whether subdirectories of the root are descended
+	// into. If false, only the root's immediate contents are visited.
+	Recursive bool
+	// Include is a list of glob patterns, matched against each file's path
+	// relative to the root. Besides the single-segment wildcards
+	// filepath.Match supports, a "**" segment matches any number of path
+	// segments, so "**/*.go" matches ".go" files at any depth. A file must
+	// match at least one pattern to be visited. An empty list matches
+	// every file.
+	Include []string
+	// Exclude is a list of glob patterns, matched the same way as
+	// Include. A file matching any exclude pattern is never visited, even
+	// if it also matches an include pattern.
+	Exclude []string
+	// Prune is a list of directory basenames, such as ".git" or
+	// "node_modules", that are skipped entirely rather than descended
+	// into.
+	Prune []string
+}
+
+// Walk walks root, invoking visit for every entry that is not a directory
+// (after following symlinks) and that passes the
+// Include/Exclude/Prune filters in opts. cache, if non-nil, is consulted
+// and populated so that directories unchanged between repeated Walk calls
+// sharing the same cache are not re-read from disk. Symlinks are followed,
+// but a symlink that would revisit a directory already seen in this Walk is
+// skipped rather than followed, to avoid an infinite loop.
+func Walk(root string, opts Options, cache *Cache, visit func(path string, info os.FileInfo) error) error {
+	info, err := os.Lstat(root)
+	if nil != err {
+		return err
+	}
+
+	visited := make(map[uint64]bool)
+	return walk(root, root, info, true, opts, cache, visited, visit)
+}
+
+func walk(root, path string, info os.FileInfo, isRoot bool, opts Options, cache *Cache, visited map[uint64]bool, visit func(path string, info os.FileInfo) error) error {
+	isLink := info.Mode()&os.ModeSymlink != 0
+	if isLink {
+		resolved, err := os.Stat(path)
+		if nil != err {
+			// broken symlink; nothing to visit.
+			return nil
+		}
+		if ino, ok := inode(resolved); ok {
+			if visited[ino] {
+				return nil
+			}
+			visited[ino] = true
+		}
+		info = resolved
+	}
+
+	if info.IsDir() {
+		if !isRoot {
+			if IsPruned(filepath.Base(path), opts.Prune) {
+				return nil
+			}
+			if !opts.Recursive {
+				return nil
+			}
+		}
+		if !isLink {
+			if ino, ok := inode(info); ok {
+				visited[ino] = true
+			}
+		}
+
+		entries, err := cache.readDir(path, info)
+		if nil != err {
+			return err
+		}
+		for _, entry := range entries {
+			childPath := filepath.Join(path, entry.Name())
+			if err := walk(root, childPath, entry, false, opts, cache, visited, visit); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if nil != err {
+		rel = filepath.Base(path)
+	}
+	if !MatchesFilters(rel, opts.Include, opts.Exclude) {
+		return nil
+	}
+
+	return visit(path, info)
+}
+
+// IsPruned reports whether name, a directory basename, appears in prune.
+// Exported so callers outside a Walk - such as a watcher reacting to a
+// newly created directory - can apply the same prune list.
+func IsPruned(name string, prune []string) bool {
+	for _, p := range prune {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesFilters reports whether relPath, a path relative to a Walk's
+// root, passes the include/exclude glob filters: it must not match any
+// exclude pattern, and, if include is non-empty, must match at least one
+// include pattern. Exported so callers outside a Walk - such as a watcher
+// deciding whether to re-search a changed file - can apply the same rules.
+func MatchesFilters(relPath string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matchGlob(pattern, relPath) {
+			return false
+		}
+	}
+
+	if 0 == len(include) {
+		return true
+	}
+	for _, pattern := range include {
+		if matchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether name matches pattern, understanding "/" as a
+// path separator and "**" as a path segment that matches zero or more
+// segments of name, in addition to the single-segment wildcards
+// filepath.Match already supports. This is what lets a pattern like
+// "**/*.go" match both "a.go" and "sub/deeper/c.go".
+func matchGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	if 0 == len(pattern) {
+		return 0 == len(name)
+	}
+
+	if "**" == pattern[0] {
+		if matchGlobSegments(pattern[1:], name) {
+			return true
+		}
+		if 0 == len(name) {
+			return false
+		}
+		return matchGlobSegments(pattern, name[1:])
+	}
+
+	if 0 == len(name) {
+		return false
+	}
+	if ok, err := filepath.Match(pattern[0], name[0]); nil != err || !ok {
+		return false
+	}
+	return matchGlobSegments(pattern[1:], name[1:])
+}
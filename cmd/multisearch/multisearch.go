@@ -8,12 +8,15 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"unicode"
 
 	"github.com/trevor-leach/multisearch/api"
 	"github.com/trevor-leach/multisearch/api/ahocorasick"
+	"github.com/trevor-leach/multisearch/api/walker"
+	"github.com/trevor-leach/multisearch/cmd/multisearch/output"
 )
 
 type lowerRuneReader struct {
@@ -41,7 +44,7 @@ func (i *sliceStr) Set(value string) error {
 
 func usage() {
 	fmt.Print("Multisearch searches for multiple terms in some text.\n\n")
-	fmt.Print("Usage:\n\n    multisearch [--termfile path]... [--searchpath path [-r]] [-i] [search_term...]\n\n")
+	fmt.Print("Usage:\n\n    multisearch [--termfile path]... [--regex-termfile path]... [--regex pattern]... [--searchpath path [-r]] [-i] [--format={text,json,ndjson}] [search_term...]\n\n")
 	fmt.Print("Options:\n\n")
 	flag.PrintDefaults()
 }
@@ -54,6 +57,15 @@ func main() {
 	var recursive bool
 	var help bool
 	var caseInsensitive bool
+	var formatFlag string
+	var includes sliceStr
+	var excludes sliceStr
+	var prunes sliceStr
+	var indexPath string
+	var jobs int
+	var watchMode bool
+	var regexTermFiles sliceStr
+	var regexes sliceStr
 
 	flag.Var(&termFiles, "termfile", "File containing search terms, one per line.  May be specified multiple times.")
 	flag.StringVar(&searchFile, "searchpath", "", "File in which to search.  If a directory, contained files are searched.")
@@ -61,6 +73,15 @@ func main() {
 	flag.BoolVar(&recursive, "r", false, "Search all subdirectories of searchpath.")
 	flag.BoolVar(&help, "help", false, "Print this help text and exit.")
 	flag.BoolVar(&caseInsensitive, "i", false, "Perform a case-insensitive search")
+	flag.StringVar(&formatFlag, "format", string(output.Text), "Output format: text, json, or ndjson.")
+	flag.Var(&includes, "include", "Glob, relative to searchpath, a file must match to be searched.  \"**\" matches any number of path segments, e.g. \"**/*.go\".  May be specified multiple times.")
+	flag.Var(&excludes, "exclude", "Glob, relative to searchpath, that excludes a matching file from being searched.  \"**\" matches any number of path segments, e.g. \"**/*.go\".  May be specified multiple times.")
+	flag.Var(&prunes, "prune", "Directory basename, such as .git, to skip entirely while walking searchpath.  May be specified multiple times.")
+	flag.StringVar(&indexPath, "index", "", "Path to a persisted search index.  If it exists it is loaded; otherwise one is built from --termfile/search_term and written there.")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "Number of worker goroutines searching files when walking a directory.")
+	flag.BoolVar(&watchMode, "watch", false, "After the initial search of searchpath, keep running and re-search files as they change.  Only valid with a directory searchpath.")
+	flag.Var(&regexTermFiles, "regex-termfile", "File containing regular expression search terms, one per line.  May be specified multiple times.")
+	flag.Var(&regexes, "regex", "A regular expression to search for, in addition to any literal search terms.  May be specified multiple times.")
 	flag.Parse()
 
 	if help {
@@ -68,6 +89,22 @@ func main() {
 		os.Exit(0)
 	}
 
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	if watchMode && "" == searchFile {
+		fmt.Fprintln(os.Stderr, "\"-watch\" option may only be specified along with \"-searchpath\"")
+		os.Exit(1)
+	}
+
+	format, err := output.ParseFormat(formatFlag)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	enc := output.NewEncoder(format)
+
 	for _, termfile := range termFiles {
 		f, err := os.Open(termfile)
 		if nil != err {
@@ -104,7 +141,20 @@ func main() {
 		terms = append(terms, term)
 	}
 
-	if 0 == len(terms) {
+	indexExists := false
+	if "" != indexPath {
+		if _, err := os.Stat(indexPath); nil == err {
+			indexExists = true
+		}
+	}
+
+	patterns, err := readPatterns(regexTermFiles, regexes, caseInsensitive)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if 0 == len(terms) && 0 == len(patterns) && !indexExists {
 		fmt.Fprintln(os.Stderr, "no search terms specified")
 		usage()
 		os.Exit(1)
@@ -137,11 +187,39 @@ func main() {
 		}
 	}
 
-	var searcher api.Searcher = ahocorasick.New(terms)
+	trie, err := loadOrBuildIndex(indexPath, indexExists, terms)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, pattern := range patterns {
+		if err := trie.AddPatternTerm(pattern); nil != err {
+			fmt.Fprintf(os.Stderr, "regular expression %q: %s\n", pattern, err)
+			os.Exit(1)
+		}
+	}
+	var searcher api.Searcher = trie
+	var dirCache = walker.NewCache()
 	var outCh = make(chan string, 16)
 	var errCh = make(chan string, 16)
 	var wg, wg2 sync.WaitGroup
 
+	wg2.Add(1)
+	go func() {
+		defer wg2.Done()
+		for s := range outCh {
+			fmt.Fprint(out, s)
+		}
+	}()
+
+	wg2.Add(1)
+	go func() {
+		defer wg2.Done()
+		for s := range errCh {
+			fmt.Fprint(os.Stderr, s)
+		}
+	}()
+
 	if "" == searchFile {
 		if recursive {
 			fmt.Println("\"-r\" option may only be specified along with \"-searchpath\"")
@@ -154,7 +232,7 @@ func main() {
 			rr = &lowerRuneReader{rr: rr}
 		}
 
-		go doSearch(searcher, rr, outCh, &wg)
+		go doSearch(searcher, rr, enc, outCh, &wg)
 	} else {
 		info, err := os.Stat(searchFile)
 		if nil != err {
@@ -169,26 +247,37 @@ func main() {
 
 		if info.IsDir() {
 			searchFile = filepath.Clean(searchFile)
-			filepath.Walk(searchFile, func(path string, currentInfo os.FileInfo, err error) error {
-				if nil != err {
-					errCh <- fmt.Sprintf("%q: %e", path, err)
-					return nil
-				}
 
-				if os.SameFile(info, currentInfo) {
-					return nil
-				}
-				if currentInfo.IsDir() {
-					if !recursive {
-						return filepath.SkipDir
+			jobCh := make(chan searchJob, jobs)
+			for i := 0; i < jobs; i++ {
+				go func() {
+					for job := range jobCh {
+						doFileSearch(searcher, job.path, job.event, enc, outCh, errCh, caseInsensitive, &wg)
 					}
+				}()
+			}
+
+			opts := walker.Options{Recursive: recursive, Include: includes, Exclude: excludes, Prune: prunes}
+			walkErr := walker.Walk(searchFile, opts, dirCache, func(path string, currentInfo os.FileInfo) error {
+				if os.SameFile(info, currentInfo) {
 					return nil
 				}
 				wg.Add(1)
-				go doFileSearch(searcher, path, outCh, errCh, caseInsensitive, &wg)
+				jobCh <- searchJob{path: path}
 
 				return nil
 			})
+			if nil != walkErr {
+				errCh <- fmt.Sprintf("%q: %e", searchFile, walkErr)
+			}
+
+			if watchMode {
+				if err := watch(searchFile, opts, dirCache, jobCh, &wg, errCh); nil != err {
+					errCh <- fmt.Sprintf("watch %q: %s\n", searchFile, err)
+				}
+			}
+
+			close(jobCh)
 		} else {
 			fin, er := os.Open(searchFile)
 			if nil != er {
@@ -201,29 +290,14 @@ func main() {
 				rr = &lowerRuneReader{rr: rr}
 			}
 			wg.Add(1)
-			go doSearch(searcher, rr, outCh, &wg)
+			go doSearch(searcher, rr, enc, outCh, &wg)
 		}
 	}
 
-	wg2.Add(1)
-	go func() {
-		defer wg2.Done()
-		for s := range outCh {
-			fmt.Fprint(out, s)
-		}
-		//fmt.Println("exiting for loop")
-	}()
-
-	wg2.Add(1)
-	go func() {
-		defer wg2.Done()
-		for s := range errCh {
-			fmt.Fprint(os.Stderr, s)
-		}
-		//fmt.Println("exiting for loop")
-	}()
-
 	wg.Wait()
+	if trailer := enc.Finish(); "" != trailer {
+		outCh <- trailer
+	}
 	close(outCh)
 	close(errCh)
 	wg2.Wait()
@@ -240,7 +314,87 @@ func main() {
 	// fmt.Fprintln(out, string(o))
 }
 
-func doFileSearch(searcher api.Searcher, path string, out, errCh chan<- string, caseInsensitive bool, wg *sync.WaitGroup) {
+// readPatterns collects regular expression search terms from regexTermFiles
+// and regexes. Unlike literal terms, a pattern is never lowercased - doing
+// so could corrupt constructs like a "[A-Z]" character class - so
+// caseInsensitive is instead applied by prepending the "(?i)" flag, which
+// regexp understands as making the rest of the pattern case-insensitive.
+func readPatterns(regexTermFiles, regexes []string, caseInsensitive bool) ([]string, error) {
+	var patterns []string
+
+	for _, termfile := range regexTermFiles {
+		f, err := os.Open(termfile)
+		if nil != err {
+			return nil, fmt.Errorf("opening regex term file %q: %w", termfile, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			pattern := strings.TrimSpace(scanner.Text())
+			if "" == pattern {
+				continue
+			}
+			if caseInsensitive {
+				pattern = "(?i)" + pattern
+			}
+			patterns = append(patterns, pattern)
+		}
+		err = scanner.Err()
+		f.Close()
+		if nil != err {
+			return nil, fmt.Errorf("reading regex term file %q: %w", termfile, err)
+		}
+	}
+
+	for _, pattern := range regexes {
+		pattern = strings.TrimSpace(pattern)
+		if "" == pattern {
+			continue
+		}
+		if caseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// loadOrBuildIndex loads the persisted index at indexPath if it already
+// exists, otherwise builds one from terms and, when indexPath is set,
+// persists it there for future runs to reuse.
+func loadOrBuildIndex(indexPath string, indexExists bool, terms []string) (*ahocorasick.SearchTrie, error) {
+	if indexExists {
+		f, err := os.Open(indexPath)
+		if nil != err {
+			return nil, fmt.Errorf("opening index %q: %w", indexPath, err)
+		}
+		defer f.Close()
+
+		trie, err := ahocorasick.LoadIndex(f)
+		if nil != err {
+			return nil, fmt.Errorf("loading index %q: %w", indexPath, err)
+		}
+		return trie, nil
+	}
+
+	trie := ahocorasick.New(terms)
+
+	if "" != indexPath {
+		f, err := os.Create(indexPath)
+		if nil != err {
+			return nil, fmt.Errorf("creating index %q: %w", indexPath, err)
+		}
+		defer f.Close()
+
+		if err := trie.WriteIndex(f); nil != err {
+			return nil, fmt.Errorf("writing index %q: %w", indexPath, err)
+		}
+	}
+
+	return trie, nil
+}
+
+func doFileSearch(searcher api.Searcher, path, event string, enc output.Encoder, out, errCh chan<- string, caseInsensitive bool, wg *sync.WaitGroup) {
 	defer wg.Done()
 	fin, er := os.Open(path)
 	if nil != er {
@@ -252,16 +406,21 @@ func doFileSearch(searcher api.Searcher, path string, out, errCh chan<- string,
 	if caseInsensitive {
 		rr = &lowerRuneReader{rr: rr}
 	}
-	//out <- fmt.Sprintln("Name\tKeyword\tStart\tEnd")
 	for match := range searcher.Search(rr) {
-		out <- fmt.Sprintf("%s\t%s\t%d\t%d\n", path, match.Term, match.Location[0], match.Location[1])
+		if chunk := enc.EncodeWatchMatch(path, match, event); "" != chunk {
+			out <- chunk
+		}
 	}
 }
 
-func doSearch(searcher api.Searcher, in io.RuneReader, out chan<- string, wg *sync.WaitGroup) {
+func doSearch(searcher api.Searcher, in io.RuneReader, enc output.Encoder, out chan<- string, wg *sync.WaitGroup) {
 	defer wg.Done()
-	out <- fmt.Sprintln("Term\tStart\tEnd")
+	if header := enc.Header(); "" != header {
+		out <- header
+	}
 	for match := range searcher.Search(in) {
-		out <- fmt.Sprintf("%s\t%d\t%d\n", match.Term, match.Location[0], match.Location[1])
+		if chunk := enc.EncodeMatch("", match); "" != chunk {
+			out <- chunk
+		}
 	}
 }
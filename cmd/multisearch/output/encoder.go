@@ -0,0 +1,188 @@
+// Package output renders api.Match results in the formats the multisearch
+// CLI can emit: plain tab-separated text, a single JSON document, or
+// newline-delimited JSON for streaming consumers.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/trevor-leach/multisearch/api"
+)
+
+// Format identifies one of the output formats the CLI supports.
+type Format string
+
+const (
+	// Text is the original tab-separated "path\tterm\tstart\tend" format.
+	Text Format = "text"
+	// JSON emits a single JSON array, grouping matches by file, once
+	// every file has been searched.
+	JSON Format = "json"
+	// NDJSON emits one JSON object per match, written as soon as it is
+	// found.
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat validates and converts the value of a --format flag.
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case Text, JSON, NDJSON:
+		return Format(value), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q", value)
+	}
+}
+
+// Encoder renders matches found while searching one or more files.
+// EncodeMatch is called once per match, as soon as it is found, and may be
+// called concurrently from goroutines searching different files -
+// implementations must be safe for concurrent use. Finish is called exactly
+// once, after every file has finished searching, to render any trailing
+// document structure; its result, like EncodeMatch's, is written verbatim
+// to the output and may be empty.
+type Encoder interface {
+	EncodeMatch(path string, m api.Match) string
+	// EncodeWatchMatch is EncodeMatch for a match found by --watch
+	// re-searching a file after a change notification. event describes
+	// why the file was re-searched, e.g. "modified"; the JSON and NDJSON
+	// formats carry it as an "event" field, so a streaming consumer can
+	// tell an initial-pass match from a later one.
+	EncodeWatchMatch(path string, m api.Match, event string) string
+	// Header returns a leading line to write before any matches, such as
+	// the text format's column header. It is called at most once, before
+	// any call to EncodeMatch/EncodeWatchMatch, and may be empty.
+	Header() string
+	Finish() string
+}
+
+// NewEncoder returns the Encoder for the given format.
+func NewEncoder(f Format) Encoder {
+	switch f {
+	case JSON:
+		return &jsonEncoder{results: make(map[string]*fileResult)}
+	case NDJSON:
+		return &ndjsonEncoder{}
+	default:
+		return &textEncoder{}
+	}
+}
+
+// textEncoder reproduces multisearch's original tab-separated format.
+type textEncoder struct{}
+
+func (e *textEncoder) EncodeMatch(path string, m api.Match) string {
+	return e.EncodeWatchMatch(path, m, "")
+}
+
+func (e *textEncoder) EncodeWatchMatch(path string, m api.Match, event string) string {
+	if "" == path {
+		return fmt.Sprintf("%s\t%d\t%d\n", m.Term, m.Location[0], m.Location[1])
+	}
+	return fmt.Sprintf("%s\t%s\t%d\t%d\n", path, m.Term, m.Location[0], m.Location[1])
+}
+
+func (e *textEncoder) Header() string {
+	return "Term\tStart\tEnd\n"
+}
+
+func (e *textEncoder) Finish() string {
+	return ""
+}
+
+// ndjsonRecord is one line of the NDJSON format.
+type ndjsonRecord struct {
+	Path  string `json:"path,omitempty"`
+	Term  string `json:"term"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Event string `json:"event,omitempty"`
+}
+
+// ndjsonEncoder writes one match per line, as soon as it is found, so a
+// consumer can stream the output of a long recursive search without
+// buffering any one file's results.
+type ndjsonEncoder struct{}
+
+func (e *ndjsonEncoder) EncodeMatch(path string, m api.Match) string {
+	return e.EncodeWatchMatch(path, m, "")
+}
+
+func (e *ndjsonEncoder) EncodeWatchMatch(path string, m api.Match, event string) string {
+	b, err := json.Marshal(ndjsonRecord{Path: path, Term: m.Term, Start: m.Location[0], End: m.Location[1], Event: event})
+	if nil != err {
+		return ""
+	}
+	return string(b) + "\n"
+}
+
+func (e *ndjsonEncoder) Header() string {
+	return ""
+}
+
+func (e *ndjsonEncoder) Finish() string {
+	return ""
+}
+
+// matchRecord is one match within a fileResult.
+type matchRecord struct {
+	Term  string `json:"term"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Event string `json:"event,omitempty"`
+}
+
+// fileResult groups every match found in one file.
+type fileResult struct {
+	Path    string        `json:"path,omitempty"`
+	Matches []matchRecord `json:"matches"`
+}
+
+// jsonEncoder buffers matches, grouped by file, and renders them as a
+// single JSON array in Finish. Unlike ndjsonEncoder it cannot stream,
+// since the array it produces has to be well-formed as a whole.
+type jsonEncoder struct {
+	mu      sync.Mutex
+	order   []string
+	results map[string]*fileResult
+}
+
+func (e *jsonEncoder) EncodeMatch(path string, m api.Match) string {
+	return e.EncodeWatchMatch(path, m, "")
+}
+
+func (e *jsonEncoder) EncodeWatchMatch(path string, m api.Match, event string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fr, ok := e.results[path]
+	if !ok {
+		fr = &fileResult{Path: path}
+		e.results[path] = fr
+		e.order = append(e.order, path)
+	}
+	fr.Matches = append(fr.Matches, matchRecord{Term: m.Term, Start: m.Location[0], End: m.Location[1], Event: event})
+
+	return ""
+}
+
+func (e *jsonEncoder) Header() string {
+	return ""
+}
+
+func (e *jsonEncoder) Finish() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	all := make([]*fileResult, len(e.order))
+	for i, path := range e.order {
+		all[i] = e.results[path]
+	}
+
+	b, err := json.Marshal(all)
+	if nil != err {
+		return ""
+	}
+	return string(b) + "\n"
+}
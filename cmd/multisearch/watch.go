@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/trevor-leach/multisearch/api/walker"
+)
+
+// heartbeatInterval controls how often watch emits a comment line to
+// reassure a long-lived pipe consumer that the process is still alive
+// between file change events.
+const heartbeatInterval = 30 * time.Second
+
+// searchJob is one file queued for (re-)searching by the worker pool.
+// event is "" for the initial pass over searchpath, and describes the
+// fsnotify change, e.g. "modified", for anything found afterwards by
+// watch.
+type searchJob struct {
+	path  string
+	event string
+}
+
+// watch keeps the process alive after the initial pass over root, adding
+// an fsnotify watch on every directory visited (respecting opts.Recursive
+// and opts.Prune) and enqueueing changed files onto jobCh for the same
+// worker pool that handled the initial pass. cache is the same Cache the
+// initial walker.Walk over root used, so the directory listings watch needs
+// to add its own watches don't have to be re-read from disk. It returns
+// once the process receives SIGINT or SIGTERM, after every watch has been
+// closed.
+func watch(root string, opts walker.Options, cache *walker.Cache, jobCh chan<- searchJob, wg *sync.WaitGroup, errCh chan<- string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		return fmt.Errorf("starting watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := addWatches(watcher, root, cache, opts); nil != err {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-sigCh:
+			return nil
+
+		case <-heartbeat.C:
+			errCh <- "# multisearch: still watching\n"
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			handleWatchEvent(watcher, root, opts, cache, event, jobCh, wg, errCh)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			errCh <- fmt.Sprintf("watch error: %s\n", err)
+		}
+	}
+}
+
+// addWatches recursively registers dir and, when opts.Recursive is set,
+// every subdirectory not named in opts.Prune.
+func addWatches(watcher *fsnotify.Watcher, dir string, cache *walker.Cache, opts walker.Options) error {
+	return addWatchesRecursive(watcher, dir, true, cache, opts)
+}
+
+func addWatchesRecursive(watcher *fsnotify.Watcher, dir string, isRoot bool, cache *walker.Cache, opts walker.Options) error {
+	if err := watcher.Add(dir); nil != err {
+		return fmt.Errorf("watching %q: %w", dir, err)
+	}
+
+	if !opts.Recursive {
+		return nil
+	}
+
+	entries, err := cache.ReadDir(dir)
+	if nil != err {
+		return fmt.Errorf("reading %q: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if walker.IsPruned(entry.Name(), opts.Prune) {
+			continue
+		}
+		if err := addWatchesRecursive(watcher, filepath.Join(dir, entry.Name()), false, cache, opts); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleWatchEvent reacts to a single fsnotify event: a newly created
+// directory is watched in turn (when recursive), and a created or written
+// file (anything that is not a directory) that passes the include/exclude
+// filters is enqueued for re-searching.
+func handleWatchEvent(watcher *fsnotify.Watcher, root string, opts walker.Options, cache *walker.Cache, event fsnotify.Event, jobCh chan<- searchJob, wg *sync.WaitGroup, errCh chan<- string) {
+	info, err := os.Stat(event.Name)
+	if nil != err {
+		// removed or renamed away; nothing left to search or watch.
+		return
+	}
+
+	if info.IsDir() {
+		if 0 != event.Op&fsnotify.Create && opts.Recursive && !walker.IsPruned(info.Name(), opts.Prune) {
+			if err := addWatchesRecursive(watcher, event.Name, false, cache, opts); nil != err {
+				errCh <- fmt.Sprintf("%s\n", err)
+			}
+		}
+		return
+	}
+
+	if 0 == event.Op&(fsnotify.Write|fsnotify.Create) {
+		return
+	}
+
+	rel, err := filepath.Rel(root, event.Name)
+	if nil != err {
+		rel = filepath.Base(event.Name)
+	}
+	if !walker.MatchesFilters(rel, opts.Include, opts.Exclude) {
+		return
+	}
+
+	wg.Add(1)
+	jobCh <- searchJob{path: event.Name, event: "modified"}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/trevor-leach/multisearch/api/walker"
+)
+
+// waitForEvent reads from watcher.Events until it sees one matching want, or
+// fails the test after a few seconds - long enough for a real filesystem
+// notification to arrive, but not so long a hung test blocks CI.
+func waitForEvent(t *testing.T, watcher *fsnotify.Watcher, want string) fsnotify.Event {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-watcher.Events:
+			if event.Name == want {
+				return event
+			}
+		case err := <-watcher.Errors:
+			t.Fatalf("watcher error: %s", err)
+		case <-deadline:
+			t.Fatalf("timed out waiting for an event on %q", want)
+		}
+	}
+}
+
+// TestAddWatchesRecursiveSkipsPrunedDirectories checks that a pruned
+// directory, and everything under it, never gets an fsnotify watch added.
+func TestAddWatchesRecursiveSkipsPrunedDirectories(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	pruned := filepath.Join(root, ".git")
+	prunedChild := filepath.Join(pruned, "objects")
+	for _, dir := range []string{sub, prunedChild} {
+		if err := os.MkdirAll(dir, 0o755); nil != err {
+			t.Fatalf("MkdirAll(%q): %s", dir, err)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		t.Fatalf("NewWatcher: %s", err)
+	}
+	defer watcher.Close()
+
+	opts := walker.Options{Recursive: true, Prune: []string{".git"}}
+	if err := addWatches(watcher, root, nil, opts); nil != err {
+		t.Fatalf("addWatches: %s", err)
+	}
+
+	watched := watcher.WatchList()
+	sort.Strings(watched)
+
+	for _, dir := range []string{pruned, prunedChild} {
+		for _, w := range watched {
+			if w == dir {
+				t.Errorf("pruned directory %q was watched: %v", dir, watched)
+			}
+		}
+	}
+
+	var sawSub bool
+	for _, w := range watched {
+		if w == sub {
+			sawSub = true
+		}
+	}
+	if !sawSub {
+		t.Errorf("expected %q to be watched, got %v", sub, watched)
+	}
+}
+
+// TestHandleWatchEventEnqueuesModifiedFile drives handleWatchEvent with an
+// event read from a real fsnotify watcher on a temp directory, and checks
+// that a written file passing the include/exclude filters is enqueued with
+// event "modified".
+func TestHandleWatchEventEnqueuesModifiedFile(t *testing.T) {
+	root := t.TempDir()
+
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		t.Fatalf("NewWatcher: %s", err)
+	}
+	defer watcher.Close()
+
+	opts := walker.Options{Recursive: true, Include: []string{"*.go"}}
+	if err := addWatches(watcher, root, nil, opts); nil != err {
+		t.Fatalf("addWatches: %s", err)
+	}
+
+	target := filepath.Join(root, "a.go")
+	if err := os.WriteFile(target, []byte("package root\n"), 0o644); nil != err {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	event := waitForEvent(t, watcher, target)
+
+	jobCh := make(chan searchJob, 1)
+	errCh := make(chan string, 1)
+	var wg sync.WaitGroup
+
+	handleWatchEvent(watcher, root, opts, nil, event, jobCh, &wg, errCh)
+
+	select {
+	case job := <-jobCh:
+		if job.path != target {
+			t.Errorf("job.path = %q, want %q", job.path, target)
+		}
+		if job.event != "modified" {
+			t.Errorf("job.event = %q, want %q", job.event, "modified")
+		}
+		wg.Done()
+	default:
+		t.Fatal("expected a job to be enqueued for the written file")
+	}
+}
+
+// TestHandleWatchEventIgnoresExcludedFile checks that a write to a file not
+// passing the include filter is not enqueued.
+func TestHandleWatchEventIgnoresExcludedFile(t *testing.T) {
+	root := t.TempDir()
+
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		t.Fatalf("NewWatcher: %s", err)
+	}
+	defer watcher.Close()
+
+	opts := walker.Options{Recursive: true, Include: []string{"*.go"}}
+	if err := addWatches(watcher, root, nil, opts); nil != err {
+		t.Fatalf("addWatches: %s", err)
+	}
+
+	target := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(target, []byte("not go\n"), 0o644); nil != err {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	event := waitForEvent(t, watcher, target)
+
+	jobCh := make(chan searchJob, 1)
+	errCh := make(chan string, 1)
+	var wg sync.WaitGroup
+
+	handleWatchEvent(watcher, root, opts, nil, event, jobCh, &wg, errCh)
+
+	select {
+	case job := <-jobCh:
+		t.Errorf("expected no job for an excluded file, got %+v", job)
+	default:
+	}
+}
+
+// TestHandleWatchEventWatchesNewSubdirectory checks that a subdirectory
+// created after the initial addWatches pass is itself watched, so a file
+// written into it afterwards is picked up too.
+func TestHandleWatchEventWatchesNewSubdirectory(t *testing.T) {
+	root := t.TempDir()
+
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		t.Fatalf("NewWatcher: %s", err)
+	}
+	defer watcher.Close()
+
+	opts := walker.Options{Recursive: true, Include: []string{"**/*.go"}}
+	if err := addWatches(watcher, root, nil, opts); nil != err {
+		t.Fatalf("addWatches: %s", err)
+	}
+
+	newDir := filepath.Join(root, "sub")
+	if err := os.Mkdir(newDir, 0o755); nil != err {
+		t.Fatalf("Mkdir: %s", err)
+	}
+	dirEvent := waitForEvent(t, watcher, newDir)
+
+	jobCh := make(chan searchJob, 1)
+	errCh := make(chan string, 1)
+	var wg sync.WaitGroup
+
+	handleWatchEvent(watcher, root, opts, nil, dirEvent, jobCh, &wg, errCh)
+
+	var watchedNewDir bool
+	for _, w := range watcher.WatchList() {
+		if w == newDir {
+			watchedNewDir = true
+		}
+	}
+	if !watchedNewDir {
+		t.Fatalf("expected %q to be watched after its Create event, got %v", newDir, watcher.WatchList())
+	}
+
+	target := filepath.Join(newDir, "b.go")
+	if err := os.WriteFile(target, []byte("package sub\n"), 0o644); nil != err {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	fileEvent := waitForEvent(t, watcher, target)
+
+	handleWatchEvent(watcher, root, opts, nil, fileEvent, jobCh, &wg, errCh)
+
+	select {
+	case job := <-jobCh:
+		if job.path != target {
+			t.Errorf("job.path = %q, want %q", job.path, target)
+		}
+		wg.Done()
+	default:
+		t.Fatal("expected a job to be enqueued for a file written into the new subdirectory")
+	}
+}
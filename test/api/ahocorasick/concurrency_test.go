@@ -0,0 +1,38 @@
+package ahocorasick
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/trevor-leach/multisearch/api"
+	"github.com/trevor-leach/multisearch/api/ahocorasick"
+)
+
+// TestSearchConcurrentUse locks in api.Searcher's documented guarantee that
+// a single SearchTrie may be searched by many goroutines at once, each with
+// its own text, once construction has finished. Run with -race.
+func TestSearchConcurrentUse(t *testing.T) {
+	var s api.Searcher = ahocorasick.New([]string{"an", "a", "can"})
+	texts := []string{
+		"you can do it!",
+		"an ant can carry a crumb",
+		"nothing to see here",
+		"a can of cans",
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		text := texts[i%len(texts)]
+		wg.Add(1)
+		go func(text string) {
+			defer wg.Done()
+			for range s.Search(strings.NewReader(text)) {
+				// draining the channel is enough to exercise the shared
+				// trie concurrently; correctness of individual matches
+				// is covered by TestNew.
+			}
+		}(text)
+	}
+	wg.Wait()
+}
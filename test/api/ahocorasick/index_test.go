@@ -0,0 +1,118 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/trevor-leach/multisearch/api"
+	"github.com/trevor-leach/multisearch/api/ahocorasick"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	terms := []string{"an", "a", "can", "cannot", "announce"}
+	text := "you can do it! announce that you cannot be stopped."
+
+	original := ahocorasick.New(terms)
+
+	var buf bytes.Buffer
+	if err := original.WriteIndex(&buf); nil != err {
+		t.Fatalf("WriteIndex: %s", err)
+	}
+
+	loaded, err := ahocorasick.LoadIndex(bytes.NewReader(buf.Bytes()))
+	if nil != err {
+		t.Fatalf("LoadIndex: %s", err)
+	}
+
+	want := collectMatches(t, original.Search(strings.NewReader(text)))
+	got := collectMatches(t, loaded.Search(strings.NewReader(text)))
+
+	if len(want) != len(got) {
+		t.Fatalf("expected %d matches, got %d: %v", len(want), len(got), got)
+	}
+	for m := range want {
+		if !got[m] {
+			t.Errorf("loaded index missed match: %+v", m)
+		}
+	}
+}
+
+func TestIndexRoundTripAllowsFurtherInsertion(t *testing.T) {
+	original := ahocorasick.New([]string{"an", "can"})
+
+	var buf bytes.Buffer
+	if err := original.WriteIndex(&buf); nil != err {
+		t.Fatalf("WriteIndex: %s", err)
+	}
+
+	loaded, err := ahocorasick.LoadIndex(bytes.NewReader(buf.Bytes()))
+	if nil != err {
+		t.Fatalf("LoadIndex: %s", err)
+	}
+
+	var s api.Searcher = loaded
+	s.AddSearchTerm("a")
+
+	got := collectMatches(t, s.Search(strings.NewReader("you can do it!")))
+
+	expected := map[api.Match]bool{
+		{Term: "a", Location: [2]int{5, 6}}:   true,
+		{Term: "an", Location: [2]int{5, 7}}:  true,
+		{Term: "can", Location: [2]int{4, 7}}: true,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for m := range expected {
+		if !got[m] {
+			t.Errorf("missed expected match after adding a term post-load: %+v", m)
+		}
+	}
+}
+
+// TestIndexRoundTripDenseChildren exercises the dense child encoding by
+// giving the root a wide enough fan-out to cross denseChildThreshold.
+func TestIndexRoundTripDenseChildren(t *testing.T) {
+	var terms []string
+	for c := 'A'; c <= 'z'; c++ {
+		terms = append(terms, string(c)+"x")
+	}
+
+	original := ahocorasick.New(terms)
+
+	var buf bytes.Buffer
+	if err := original.WriteIndex(&buf); nil != err {
+		t.Fatalf("WriteIndex: %s", err)
+	}
+
+	loaded, err := ahocorasick.LoadIndex(bytes.NewReader(buf.Bytes()))
+	if nil != err {
+		t.Fatalf("LoadIndex: %s", err)
+	}
+
+	text := "the quick brown fox jumps over the lazy dog: ax bx cx dx zx"
+	want := collectMatches(t, original.Search(strings.NewReader(text)))
+	got := collectMatches(t, loaded.Search(strings.NewReader(text)))
+
+	if len(want) == 0 {
+		t.Fatal("test text produced no matches; fixture is broken")
+	}
+	if len(want) != len(got) {
+		t.Fatalf("expected %d matches, got %d: %v", len(want), len(got), got)
+	}
+	for m := range want {
+		if !got[m] {
+			t.Errorf("loaded index missed match: %+v", m)
+		}
+	}
+}
+
+func collectMatches(t *testing.T, ch <-chan api.Match) map[api.Match]bool {
+	t.Helper()
+	matches := make(map[api.Match]bool)
+	for m := range ch {
+		matches[m] = true
+	}
+	return matches
+}
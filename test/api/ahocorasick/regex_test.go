@@ -0,0 +1,97 @@
+package ahocorasick
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/trevor-leach/multisearch/api"
+	"github.com/trevor-leach/multisearch/api/ahocorasick"
+)
+
+// TestPatternTerm exercises AddPatternTerm on its own, with no literal
+// search terms registered.
+func TestPatternTerm(t *testing.T) {
+	var text string = "room 12, room 304, room ab"
+	expected := map[api.Match]bool{
+		{Term: `room \d+`, Location: [2]int{0, 7}}:  true,
+		{Term: `room \d+`, Location: [2]int{9, 17}}: true,
+	}
+
+	s := ahocorasick.New(nil)
+	if err := s.AddPatternTerm(`room \d+`); nil != err {
+		t.Fatalf("AddPatternTerm: %s", err)
+	}
+	var searcher api.Searcher = s
+
+	for m := range searcher.Search(strings.NewReader(text)) {
+		ms, _ := json.Marshal(m)
+		if _, ok := expected[m]; !ok {
+			t.Errorf("unexpected match: %s", ms)
+		}
+		delete(expected, m)
+	}
+	for m := range expected {
+		ms, _ := json.Marshal(m)
+		t.Errorf("missed expected match: %s", ms)
+	}
+}
+
+// TestLiteralAndPatternTermsTogether checks that literal terms and patterns
+// are both matched, against the same text, when both are registered.
+func TestLiteralAndPatternTermsTogether(t *testing.T) {
+	var text string = "error: code 42 in can.go"
+	expected := map[api.Match]bool{
+		{Term: "can", Location: [2]int{18, 21}}: true,
+		{Term: `\d+`, Location: [2]int{12, 14}}: true,
+	}
+
+	s := ahocorasick.New([]string{"can"})
+	if err := s.AddPatternTerm(`\d+`); nil != err {
+		t.Fatalf("AddPatternTerm: %s", err)
+	}
+	var searcher api.Searcher = s
+
+	for m := range searcher.Search(strings.NewReader(text)) {
+		ms, _ := json.Marshal(m)
+		if _, ok := expected[m]; !ok {
+			t.Errorf("unexpected match: %s", ms)
+		}
+		delete(expected, m)
+	}
+	for m := range expected {
+		ms, _ := json.Marshal(m)
+		t.Errorf("missed expected match: %s", ms)
+	}
+}
+
+// TestPatternTermCaseInsensitive checks the documented (?i) convention for
+// case-insensitive patterns, rather than lowercasing the rune stream.
+func TestPatternTermCaseInsensitive(t *testing.T) {
+	var text string = "WARNING: disk full"
+
+	s := ahocorasick.New(nil)
+	if err := s.AddPatternTerm(`(?i)warning`); nil != err {
+		t.Fatalf("AddPatternTerm: %s", err)
+	}
+	var searcher api.Searcher = s
+
+	var found bool
+	for m := range searcher.Search(strings.NewReader(text)) {
+		if m.Term == `(?i)warning` && m.Location == [2]int{0, 7} {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a case-insensitive match for %q in %q", `(?i)warning`, text)
+	}
+}
+
+// TestInvalidPatternTerm checks that a malformed pattern is rejected, rather
+// than silently accepted.
+func TestInvalidPatternTerm(t *testing.T) {
+	s := ahocorasick.New(nil)
+	if err := s.AddPatternTerm("("); nil == err {
+		t.Error("expected an error compiling an invalid pattern")
+	}
+}
@@ -0,0 +1,246 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/trevor-leach/multisearch/api/walker"
+)
+
+// buildTree creates, under a fresh temp dir:
+//
+//	root/
+//	  a.go
+//	  a.txt
+//	  .git/ignored.go
+//	  sub/
+//	    b.go
+//	    loop -> root (symlink back to root, to exercise loop detection)
+func buildTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); nil != err {
+			t.Fatalf("MkdirAll(%q): %s", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); nil != err {
+			t.Fatalf("WriteFile(%q): %s", path, err)
+		}
+	}
+
+	write("a.go", "package root\n")
+	write("a.txt", "not go\n")
+	write(filepath.Join(".git", "ignored.go"), "package git\n")
+	write(filepath.Join("sub", "b.go"), "package sub\n")
+
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); nil != err {
+		t.Fatalf("Symlink: %s", err)
+	}
+
+	return root
+}
+
+func TestWalkPrunesDirectories(t *testing.T) {
+	root := buildTree(t)
+
+	var seen []string
+	err := walker.Walk(root, walker.Options{Recursive: true, Prune: []string{".git"}}, nil, func(path string, info os.FileInfo) error {
+		seen = append(seen, path)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("Walk: %s", err)
+	}
+
+	for _, path := range seen {
+		if filepath.Base(filepath.Dir(path)) == ".git" {
+			t.Errorf("pruned directory was still walked: %s", path)
+		}
+	}
+}
+
+func TestWalkIncludeExclude(t *testing.T) {
+	root := buildTree(t)
+
+	var seen []string
+	opts := walker.Options{
+		Recursive: true,
+		Include:   []string{"**/*.go"},
+		Exclude:   []string{".git/*.go", "**/loop/**"},
+		Prune:     []string{".git"},
+	}
+	err := walker.Walk(root, opts, nil, func(path string, info os.FileInfo) error {
+		rel, _ := filepath.Rel(root, path)
+		seen = append(seen, rel)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("Walk: %s", err)
+	}
+
+	sort.Strings(seen)
+	expected := []string{"a.go", filepath.Join("sub", "b.go")}
+	sort.Strings(expected)
+
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, seen)
+			break
+		}
+	}
+}
+
+func TestWalkIncludeDoubleStarIsRecursive(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(rel string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); nil != err {
+			t.Fatalf("MkdirAll(%q): %s", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); nil != err {
+			t.Fatalf("WriteFile(%q): %s", path, err)
+		}
+	}
+
+	write("a.go")
+	write(filepath.Join("sub", "b.go"))
+	write(filepath.Join("sub", "deeper", "c.go"))
+	write("a.txt")
+
+	var seen []string
+	opts := walker.Options{Recursive: true, Include: []string{"**/*.go"}}
+	err := walker.Walk(root, opts, nil, func(path string, info os.FileInfo) error {
+		rel, _ := filepath.Rel(root, path)
+		seen = append(seen, rel)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("Walk: %s", err)
+	}
+
+	sort.Strings(seen)
+	expected := []string{
+		"a.go",
+		filepath.Join("sub", "b.go"),
+		filepath.Join("sub", "deeper", "c.go"),
+	}
+	sort.Strings(expected)
+
+	if len(seen) != len(expected) {
+		t.Fatalf("\"**/*.go\" expected %v, got %v", expected, seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("\"**/*.go\" expected %v, got %v", expected, seen)
+			break
+		}
+	}
+}
+
+func TestWalkSymlinkLoopDoesNotHang(t *testing.T) {
+	root := buildTree(t)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- walker.Walk(root, walker.Options{Recursive: true}, nil, func(path string, info os.FileInfo) error {
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if nil != err {
+			t.Fatalf("Walk: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Walk did not return; likely stuck in a symlink loop")
+	}
+}
+
+func TestWalkNonRecursiveStopsAtRoot(t *testing.T) {
+	root := buildTree(t)
+
+	var seen []string
+	err := walker.Walk(root, walker.Options{Recursive: false}, nil, func(path string, info os.FileInfo) error {
+		seen = append(seen, path)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("Walk: %s", err)
+	}
+
+	for _, path := range seen {
+		rel, _ := filepath.Rel(root, path)
+		if filepath.Dir(rel) != "." {
+			t.Errorf("non-recursive walk descended into a subdirectory: %s", path)
+		}
+	}
+}
+
+func TestCacheRepeatedWalkFindsSameFiles(t *testing.T) {
+	root := buildTree(t)
+	cache := walker.NewCache()
+
+	count := func() int {
+		n := 0
+		err := walker.Walk(root, walker.Options{Recursive: true, Prune: []string{".git"}}, cache, func(path string, info os.FileInfo) error {
+			n++
+			return nil
+		})
+		if nil != err {
+			t.Fatalf("Walk: %s", err)
+		}
+		return n
+	}
+
+	first := count()
+	second := count()
+	if first != second {
+		t.Errorf("expected repeated Walk calls over an unchanged tree to find the same files, got %d then %d", first, second)
+	}
+}
+
+// TestCacheReadDirReusesWalkEntries checks that Cache.ReadDir, the entry
+// point a caller outside of Walk uses (such as watch mode adding an
+// fsnotify watch to a directory already seen by the initial Walk), sees the
+// same cached listing Walk itself populated.
+func TestCacheReadDirReusesWalkEntries(t *testing.T) {
+	root := buildTree(t)
+	cache := walker.NewCache()
+
+	err := walker.Walk(root, walker.Options{Recursive: true, Prune: []string{".git"}}, cache, func(path string, info os.FileInfo) error {
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("Walk: %s", err)
+	}
+
+	sub := filepath.Join(root, "sub")
+	if err := os.Remove(filepath.Join(sub, "b.go")); nil != err {
+		t.Fatalf("Remove: %s", err)
+	}
+
+	entries, err := cache.ReadDir(sub)
+	if nil != err {
+		t.Fatalf("ReadDir: %s", err)
+	}
+
+	var sawRemoved bool
+	for _, entry := range entries {
+		if "b.go" == entry.Name() {
+			sawRemoved = true
+		}
+	}
+	if !sawRemoved {
+		t.Error("expected ReadDir to return the cached listing from Walk, not a fresh read reflecting the deletion")
+	}
+}
@@ -0,0 +1,150 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/trevor-leach/multisearch/api"
+	"github.com/trevor-leach/multisearch/cmd/multisearch/output"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, value := range []string{"text", "json", "ndjson"} {
+		if _, err := output.ParseFormat(value); nil != err {
+			t.Errorf("ParseFormat(%q) returned unexpected error: %s", value, err)
+		}
+	}
+
+	if _, err := output.ParseFormat("xml"); nil == err {
+		t.Errorf("ParseFormat(\"xml\") should have returned an error")
+	}
+}
+
+// TestHeader checks that only the text format emits a leading header line.
+func TestHeader(t *testing.T) {
+	if got, want := output.NewEncoder(output.Text).Header(), "Term\tStart\tEnd\n"; got != want {
+		t.Errorf("Text Header() = %q, want %q", got, want)
+	}
+	if got := output.NewEncoder(output.JSON).Header(); "" != got {
+		t.Errorf("JSON Header() = %q, want empty", got)
+	}
+	if got := output.NewEncoder(output.NDJSON).Header(); "" != got {
+		t.Errorf("NDJSON Header() = %q, want empty", got)
+	}
+}
+
+// TestTextEncoderOmitsPathColumnWhenPathEmpty pins down the single-file and
+// stdin search format: no path is known, so EncodeMatch("", ...) must not
+// print a leading empty column under the 3-column Header().
+func TestTextEncoderOmitsPathColumnWhenPathEmpty(t *testing.T) {
+	enc := output.NewEncoder(output.Text)
+	got := enc.EncodeMatch("", api.Match{Term: "foo", Location: [2]int{12, 15}})
+	want := "foo\t12\t15\n"
+	if got != want {
+		t.Errorf("EncodeMatch(\"\", ...) = %q, want %q", got, want)
+	}
+}
+
+// TestJSONAndNDJSONOmitPathWhenEmpty checks that a single-file/stdin search,
+// which has no path to report, doesn't leak a hardcoded empty "path" field.
+func TestJSONAndNDJSONOmitPathWhenEmpty(t *testing.T) {
+	ndjsonEnc := output.NewEncoder(output.NDJSON)
+	line := ndjsonEnc.EncodeMatch("", api.Match{Term: "foo", Location: [2]int{12, 15}})
+	if strings.Contains(line, `"path"`) {
+		t.Errorf("NDJSON line has a \"path\" field for an empty path: %q", line)
+	}
+
+	jsonEnc := output.NewEncoder(output.JSON)
+	jsonEnc.EncodeMatch("", api.Match{Term: "foo", Location: [2]int{12, 15}})
+	doc := jsonEnc.Finish()
+	if strings.Contains(doc, `"path"`) {
+		t.Errorf("JSON document has a \"path\" field for an empty path: %q", doc)
+	}
+}
+
+// TestJSONEncoderConcurrent simulates several files being searched at once
+// and checks that the single JSON document produced by Finish is
+// well-formed and groups every match under its file.
+func TestJSONEncoderConcurrent(t *testing.T) {
+	enc := output.NewEncoder(output.JSON)
+	paths := []string{"a.txt", "b.txt", "c.txt"}
+
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				enc.EncodeMatch(path, api.Match{Term: "needle", Location: [2]int{i, i + 6}})
+			}
+		}(path)
+	}
+	wg.Wait()
+
+	doc := enc.Finish()
+
+	var results []struct {
+		Path    string `json:"path"`
+		Matches []struct {
+			Term  string `json:"term"`
+			Start int    `json:"start"`
+			End   int    `json:"end"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal([]byte(doc), &results); nil != err {
+		t.Fatalf("Finish produced invalid JSON: %s\n%s", err, doc)
+	}
+
+	if len(results) != len(paths) {
+		t.Errorf("expected %d files in the result, got %d", len(paths), len(results))
+	}
+	for _, r := range results {
+		if len(r.Matches) != 10 {
+			t.Errorf("expected 10 matches for %q, got %d", r.Path, len(r.Matches))
+		}
+	}
+}
+
+// TestNDJSONEncoderConcurrent checks that each line written by a concurrent
+// NDJSON encoding is independently valid JSON, even while multiple files are
+// being searched at once.
+func TestNDJSONEncoderConcurrent(t *testing.T) {
+	enc := output.NewEncoder(output.NDJSON)
+
+	var mu sync.Mutex
+	var lines []string
+	var wg sync.WaitGroup
+
+	for f := 0; f < 5; f++ {
+		wg.Add(1)
+		go func(f int) {
+			defer wg.Done()
+			path := fmt.Sprintf("file%d.txt", f)
+			for i := 0; i < 20; i++ {
+				chunk := enc.EncodeMatch(path, api.Match{Term: "needle", Location: [2]int{i, i + 6}})
+				mu.Lock()
+				lines = append(lines, chunk)
+				mu.Unlock()
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	if len(lines) != 100 {
+		t.Fatalf("expected 100 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var rec struct {
+			Path  string `json:"path"`
+			Term  string `json:"term"`
+			Start int    `json:"start"`
+			End   int    `json:"end"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSuffix(line, "\n")), &rec); nil != err {
+			t.Errorf("line is not valid JSON: %q: %s", line, err)
+		}
+	}
+}